@@ -0,0 +1,60 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// refCounts tracks the number of *extra* outstanding references to a *Chunk
+// beyond its original owning List. A chunk that has never been shared has
+// no entry here at all, which is the common case and costs nothing; only
+// List.Snapshot (or an advanced caller using Retain directly) causes an
+// entry to be created. Every Retain MUST be matched by a Release (List's
+// own Snapshot.Close does this automatically) or the entry, and the chunk
+// it keys on, are pinned forever.
+var refCounts sync.Map // map[*Chunk]*atomic.Int32
+
+func refCountOf(chk *Chunk) *atomic.Int32 {
+	v, _ := refCounts.LoadOrStore(chk, new(atomic.Int32))
+	return v.(*atomic.Int32)
+}
+
+// Retain bumps chk's reference count and returns chk, so callers can write
+// `kept := chk.Retain()`. A freshly allocated chunk is implicitly owned by
+// exactly one List; Retain is for callers (including List.Snapshot) that
+// need the chunk to outlive that List's own Reset/Clear.
+func (chk *Chunk) Retain() *Chunk {
+	refCountOf(chk).Add(1)
+	return chk
+}
+
+// Release undoes a previous Retain. It does not free anything by itself —
+// it only makes the chunk eligible, once its count reaches zero, for its
+// owning List to recycle it through the freelist again.
+func (chk *Chunk) Release() {
+	if refCountOf(chk).Add(-1) <= 0 {
+		refCounts.Delete(chk)
+	}
+}
+
+// shared reports whether chk has at least one outstanding Retain beyond its
+// original owner, i.e. whether some List.Snapshot (or other advanced
+// caller) is still relying on it.
+func shared(chk *Chunk) bool {
+	v, ok := refCounts.Load(chk)
+	return ok && v.(*atomic.Int32).Load() > 0
+}
@@ -15,10 +15,11 @@
 package chunk
 
 import (
+	"sync"
 	"unsafe"
 
-	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/chunk/spill"
 	"github.com/pingcap/tidb/pkg/util/memory"
 )
 
@@ -33,6 +34,39 @@ type List struct {
 
 	memTracker  *memory.Tracker // track memory usage.
 	consumedIdx int             // chunk index in "chunks", has been consumed.
+
+	// isSnapshot is true only for a List returned by Snapshot; it gates
+	// Close's chunk-releasing behavior.
+	isSnapshot bool
+
+	// The fields below are only set by NewListWithSpill; a List created via
+	// NewList/NewListWithMemTracker never spills and leaves them zero.
+	spillBackend spill.Backend
+	spillDir     string
+	softLimit    int64
+	evicted      map[uint32]struct{} // chunk indices currently spilled to spillBackend.
+	rehydrated   map[uint32]*Chunk   // small LRU cache of chunks paged back from spillBackend.
+	// rehydrateOrder tracks rehydrated's recency order, oldest-accessed
+	// first, so the cache can evict the true least-recently-used entry
+	// instead of an arbitrary one.
+	rehydrateOrder []uint32
+	// spillMu guards l.chunks/l.evicted/l.rehydrated against concurrent
+	// access between AppendRow's chunk-rollover bookkeeping and the
+	// background goroutine spillDiskAction launches; spilling tracks
+	// whether that goroutine is currently running, so Action never queues
+	// more than one at a time.
+	spillMu  sync.Mutex
+	spilling int32
+
+	// dedup is only set by NewDedupList; a List created via
+	// NewList/NewListWithMemTracker never deduplicates chunks.
+	dedup *dedupState
+
+	// pool is only set by NewListWithPool. When set, it takes over from
+	// freelist: consumed chunks are offered to the shared Pool instead of
+	// being kept in this List's own freelist, and allocChunk checks the
+	// Pool before falling back to Renew/New.
+	pool *Pool
 }
 
 // RowPtrSize shows the size of RowPtr
@@ -62,6 +96,18 @@ func NewList(fieldTypes []*types.FieldType, initChunkSize, maxChunkSize int) *Li
 	return NewListWithMemTracker(fieldTypes, initChunkSize, maxChunkSize, memory.NewTracker(memory.LabelForChunkList, -1))
 }
 
+// NewListWithPool creates a new List that draws recycled chunks from a
+// shared, process-wide Pool instead of keeping its own private freelist.
+// This is meant for workloads where many short-lived Lists of the same
+// shape (column count and capacity) churn continuously, so the cost of
+// repeated large allocations is paid once by the Pool instead of by every
+// List.
+func NewListWithPool(fieldTypes []*types.FieldType, initChunkSize, maxChunkSize int, tracker *memory.Tracker, pool *Pool) *List {
+	l := NewListWithMemTracker(fieldTypes, initChunkSize, maxChunkSize, tracker)
+	l.pool = pool
+	return l
+}
+
 // GetMemTracker returns the memory tracker of this List.
 func (l *List) GetMemTracker() *memory.Tracker {
 	return l.memTracker
@@ -83,17 +129,37 @@ func (l *List) FieldTypes() []*types.FieldType {
 }
 
 // NumRowsOfChunk returns the number of rows of a chunk in the DataInDiskByRows.
+// If the chunk has been spilled to disk, it is transparently rehydrated first.
 func (l *List) NumRowsOfChunk(chkID int) int {
-	return l.chunks[chkID].NumRows()
+	chk, err := l.getChunk(uint32(chkID))
+	if err != nil {
+		// Same no-error-return contract as GetRow: a corrupt or missing
+		// spill file is unrecoverable for this call.
+		panic(err)
+	}
+	return chk.NumRows()
 }
 
-// GetChunk gets the Chunk by ChkIdx.
+// GetChunk gets the Chunk by ChkIdx. If the chunk has been spilled to disk,
+// it is transparently rehydrated first.
 func (l *List) GetChunk(chkIdx int) *Chunk {
-	return l.chunks[chkIdx]
+	chk, err := l.getChunk(uint32(chkIdx))
+	if err != nil {
+		panic(err)
+	}
+	return chk
 }
 
 // AppendRow appends a row to the List, the row is copied to the List.
 func (l *List) AppendRow(row Row) RowPtr {
+	if l.spillBackend != nil {
+		// A background spillDiskAction goroutine may be mutating l.chunks
+		// (and the consumedIdx invariant this block relies on) concurrently;
+		// take the same lock it holds. This never touches disk itself, so it
+		// doesn't reintroduce the hot-path I/O spillDiskAction avoids.
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+	}
 	chkIdx := len(l.chunks) - 1
 	if chkIdx == -1 || l.chunks[chkIdx].NumRows() >= l.chunks[chkIdx].Capacity() || chkIdx == l.consumedIdx {
 		newChk := l.allocChunk()
@@ -101,6 +167,7 @@ func (l *List) AppendRow(row Row) RowPtr {
 		if chkIdx != l.consumedIdx {
 			l.memTracker.Consume(l.chunks[chkIdx].MemoryUsage())
 			l.consumedIdx = chkIdx
+			l.maybeDedupFilledChunk(chkIdx)
 		}
 		chkIdx++
 	}
@@ -119,6 +186,12 @@ func (l *List) Add(chk *Chunk) {
 		// TODO: return error here.
 		panic("chunk appended to List should have at least 1 row")
 	}
+	if l.spillBackend != nil {
+		// See the same lock in AppendRow: the background spillDiskAction
+		// goroutine mutates l.chunks/consumedIdx concurrently.
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+	}
 	if chkIdx := len(l.chunks) - 1; l.consumedIdx != chkIdx {
 		l.memTracker.Consume(l.chunks[chkIdx].MemoryUsage())
 		l.consumedIdx = chkIdx
@@ -138,52 +211,216 @@ func (l *List) allocChunk() (chk *Chunk) {
 		chk.Reset()
 		return
 	}
+	if l.pool != nil {
+		if chk = l.pool.Get(l.fieldTypes, l.maxChunkSize); chk != nil {
+			return chk
+		}
+	}
 	if len(l.chunks) > 0 {
 		return Renew(l.chunks[len(l.chunks)-1], l.maxChunkSize)
 	}
 	return New(l.fieldTypes, l.initChunkSize, l.maxChunkSize)
 }
 
-// GetRow gets a Row from the list by RowPtr.
+// GetRow gets a Row from the list by RowPtr. If the chunk holding the row
+// has been spilled to disk, it is transparently rehydrated first.
 func (l *List) GetRow(ptr RowPtr) Row {
+	if l.spillBackend != nil {
+		chk, err := l.getChunk(ptr.ChkIdx)
+		if err != nil {
+			// The row container contract has no error return here; a
+			// corrupt or missing spill file is unrecoverable for this call.
+			panic(err)
+		}
+		return chk.GetRow(int(ptr.RowIdx))
+	}
 	chk := l.chunks[ptr.ChkIdx]
 	return chk.GetRow(int(ptr.RowIdx))
 }
 
+// Snapshot returns a read-only *List sharing this List's current chunks
+// instead of copying them, for cheap fan-out in operators (hash join, CTEs,
+// window functions) that currently deep-copy rows to hand a stable view to
+// more than one consumer. Every chunk visible in the snapshot is Retain'd,
+// so this List's own Reset/Clear can't recycle a chunk the snapshot still
+// needs.
+//
+// The snapshot never gets a spill backend of its own: any chunk l has
+// currently evicted to disk is rehydrated up front instead, so the returned
+// List is always fully in-memory and its GetRow/Walk never have to consult
+// a backend at all.
+//
+// Callers must not AppendRow/Add to the returned List, and MUST call
+// Close() on it once they're done, or every chunk it retained is pinned in
+// memory (and blocked from this List's freelist/pool) forever.
+func (l *List) Snapshot() *List {
+	snap := &List{
+		fieldTypes:    l.fieldTypes,
+		initChunkSize: l.initChunkSize,
+		maxChunkSize:  l.maxChunkSize,
+		length:        l.length,
+		chunks:        make([]*Chunk, len(l.chunks)),
+		consumedIdx:   len(l.chunks) - 1,
+		memTracker:    memory.NewTracker(memory.LabelForChunkList, -1),
+		isSnapshot:    true,
+	}
+	if l.spillBackend != nil {
+		l.spillMu.Lock()
+	}
+	copy(snap.chunks, l.chunks)
+	if l.spillBackend != nil {
+		l.spillMu.Unlock()
+	}
+	if l.spillBackend != nil {
+		// l.chunks can have nil entries for chunks currently spilled to
+		// disk; the snapshot has no backend of its own to rehydrate them
+		// from later (see above), so page them back in now via l's backend
+		// instead of copying the nil and panicking on a later GetRow/Walk.
+		for i, chk := range snap.chunks {
+			if chk != nil {
+				continue
+			}
+			rehydrated, err := l.getChunk(uint32(i))
+			if err != nil {
+				panic(err)
+			}
+			snap.chunks[i] = rehydrated
+		}
+	}
+	// A dedup-enabled List can have the same *Chunk aliased into more than
+	// one slot of l.chunks (see maybeDedupFilledChunk); seen makes sure such
+	// a chunk is Retain'd/accounted for exactly once, not once per slot that
+	// happens to point at it (same pattern as Reset's seen map).
+	var seen map[*Chunk]struct{}
+	if l.dedup != nil {
+		seen = make(map[*Chunk]struct{}, len(snap.chunks))
+	}
+	for _, chk := range snap.chunks {
+		if chk == nil {
+			continue
+		}
+		if seen != nil {
+			if _, ok := seen[chk]; ok {
+				continue
+			}
+			seen[chk] = struct{}{}
+		}
+		chk.Retain()
+		// snap accounts for the chunk's bytes from the moment it retains
+		// them, independent of l: both have a live claim on the same bytes
+		// until whichever of them gives the chunk up first (l via
+		// Reset/Clear, snap via Close).
+		snap.memTracker.Consume(chk.MemoryUsage())
+	}
+	return snap
+}
+
+// Close releases every chunk this Snapshot retained, so the original List
+// (or whichever Snapshot is last standing) can recycle them again. It is a
+// no-op on a List that wasn't produced by Snapshot.
+func (l *List) Close() error {
+	if l.isSnapshot {
+		// Snapshot Retain'd a chunk aliased into several slots only once, so
+		// release each distinct chunk once as well.
+		seen := make(map[*Chunk]struct{}, len(l.chunks))
+		for _, chk := range l.chunks {
+			if chk == nil {
+				continue
+			}
+			if _, ok := seen[chk]; ok {
+				continue
+			}
+			seen[chk] = struct{}{}
+			chk.Release()
+		}
+		l.chunks = nil
+		l.memTracker.Consume(-l.memTracker.BytesConsumed())
+	}
+	return l.dropSpill()
+}
+
 // Reset resets the List.
 func (l *List) Reset() {
+	if l.spillBackend != nil {
+		// Guards l.chunks/l.consumedIdx against the background
+		// spillDiskAction goroutine the same way AppendRow/Add do: without
+		// this, a spill mid-flight on the pre-reset l.chunks/consumedIdx can
+		// null out or rehydrate-the-wrong-bytes for a chunk this call has
+		// already hand back to the freelist/pool and a subsequent AppendRow
+		// has since reused.
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+	}
 	if lastIdx := len(l.chunks) - 1; lastIdx != l.consumedIdx {
-		l.memTracker.Consume(l.chunks[lastIdx].MemoryUsage())
+		if chk := l.chunks[lastIdx]; chk != nil {
+			l.memTracker.Consume(chk.MemoryUsage())
+		}
+	}
+	l.purgeSpill()
+	// A dedup-enabled List can have the same *Chunk aliased into more than
+	// one slot of l.chunks (see maybeDedupFilledChunk); seen makes sure
+	// such a chunk's memory/freelist/pool handling below runs exactly once
+	// per distinct chunk, not once per slot that happens to point at it.
+	var seen map[*Chunk]struct{}
+	if l.dedup != nil {
+		seen = make(map[*Chunk]struct{}, len(l.chunks))
+	}
+	for _, chk := range l.chunks {
+		if chk == nil {
+			continue
+		}
+		if seen != nil {
+			if _, ok := seen[chk]; ok {
+				continue
+			}
+			seen[chk] = struct{}{}
+		}
+		if shared(chk) {
+			// A Snapshot still references this chunk; handing it to the
+			// freelist would let a future allocChunk call Reset it out from
+			// under that snapshot. The Snapshot has tracked its own claim on
+			// these bytes since it retained the chunk (see Snapshot), so
+			// this List only needs to give up its own claim here.
+			l.memTracker.Consume(-chk.MemoryUsage())
+			continue
+		}
+		if l.pool != nil {
+			l.memTracker.Consume(-chk.MemoryUsage())
+			l.pool.Put(l.fieldTypes, l.maxChunkSize, chk)
+			continue
+		}
+		l.freelist = append(l.freelist, chk)
 	}
-	l.freelist = append(l.freelist, l.chunks...)
 	l.chunks = l.chunks[:0]
 	l.length = 0
 	l.consumedIdx = -1
+	if l.dedup != nil {
+		// l.chunks is about to be rebuilt from index 0; stale indices left
+		// in the fingerprint index from the previous generation would point
+		// past the end of the new, shorter l.chunks until it grows back out,
+		// and a fingerprint collision with one of them would panic.
+		clear(l.dedup.index)
+	}
 }
 
 // Clear triggers GC for all the allocated chunks and reset the list
 func (l *List) Clear() {
+	if l.spillBackend != nil {
+		// See the same lock in Reset.
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+	}
 	l.memTracker.Consume(-l.memTracker.BytesConsumed())
+	l.purgeSpill()
 	l.freelist = nil
 	l.chunks = nil
 	l.length = 0
 	l.consumedIdx = -1
+	if l.dedup != nil {
+		clear(l.dedup.index)
+	}
 }
 
 // ListWalkFunc is used to walk the list.
 // If error is returned, it will stop walking.
 type ListWalkFunc = func(row Row) error
-
-// Walk iterate the list and call walkFunc for each row.
-func (l *List) Walk(walkFunc ListWalkFunc) error {
-	for i := range l.chunks {
-		chk := l.chunks[i]
-		for j := range chk.NumRows() {
-			err := walkFunc(chk.GetRow(j))
-			if err != nil {
-				return errors.Trace(err)
-			}
-		}
-	}
-	return nil
-}
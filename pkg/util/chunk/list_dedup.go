@@ -0,0 +1,151 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"bytes"
+
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/memory"
+)
+
+// DedupOptions configures NewDedupList. MinBlockRows/MaxBlockRows gate which
+// chunks are even worth indexing; AvgBlockRows becomes the List's own
+// init/max chunk size, since a chunk is the only granularity at which a
+// duplicate can be aliased without invalidating RowPtrs already handed out
+// mid-chunk.
+type DedupOptions struct {
+	MinBlockRows int
+	AvgBlockRows int
+	MaxBlockRows int
+}
+
+// dedupState carries the extra bookkeeping a dedup-enabled List needs beyond
+// a plain List: a fingerprint index of previously stored chunks, and
+// EXPLAIN ANALYZE-facing counters.
+type dedupState struct {
+	opts  DedupOptions
+	index map[uint64][]int // fingerprint -> indices into l.chunks of candidate blocks
+
+	bytesSaved int64
+	hits       int64
+}
+
+// NewDedupList creates a List that opportunistically aliases a newly filled
+// chunk to an earlier, byte-identical one instead of keeping a second copy
+// in memory. This is aimed at workloads with repeated grouping keys or
+// Cartesian-product intermediates, where many chunks end up with identical
+// content.
+//
+// This is whole-chunk (fixed-block) dedup, not content-defined chunking: a
+// duplicate is only ever found between two chunks that landed on the same
+// row-count boundaries as each other, and the Rabin rolling hasher is used
+// purely as fingerprintChunk's digest function over each chunk's already-
+// encoded bytes, not to locate duplicate byte ranges that straddle chunk
+// boundaries the way a true content-defined-chunking scheme would. That's
+// an intentional scope cut, not an oversight: RowPtr{ChkIdx, RowIdx}
+// addressing only lets a duplicate be aliased at the granularity of a whole
+// chunk, since aliasing anything finer would invalidate RowPtrs already
+// handed out for rows inside it.
+//
+// Rows are appended exactly like a plain List; the dedup check only runs
+// once a chunk fills up (i.e. once opts.AvgBlockRows rows have landed in
+// it), because that's the only point a whole chunk can be swapped out
+// without invalidating RowPtrs already returned for rows inside it.
+func NewDedupList(fieldTypes []*types.FieldType, tracker *memory.Tracker, opts DedupOptions) *List {
+	l := NewListWithMemTracker(fieldTypes, opts.AvgBlockRows, opts.AvgBlockRows, tracker)
+	l.dedup = &dedupState{
+		opts:  opts,
+		index: make(map[uint64][]int),
+	}
+	return l
+}
+
+// DedupBytesSaved reports how many bytes of chunk storage this List has
+// avoided allocating by aliasing duplicate blocks. It returns 0 for a List
+// not created via NewDedupList.
+func (l *List) DedupBytesSaved() int64 {
+	if l.dedup == nil {
+		return 0
+	}
+	return l.dedup.bytesSaved
+}
+
+// DedupHits reports how many chunks this List has aliased to an earlier,
+// identical one instead of storing separately.
+func (l *List) DedupHits() int64 {
+	if l.dedup == nil {
+		return 0
+	}
+	return l.dedup.hits
+}
+
+// maybeDedupFilledChunk is called right after chkIdx is retired (the next
+// AppendRow moved on to a new chunk), giving it a chance to alias chkIdx's
+// chunk to an earlier one with identical content.
+func (l *List) maybeDedupFilledChunk(chkIdx int) {
+	if l.dedup == nil || chkIdx < 0 {
+		return
+	}
+	chk := l.chunks[chkIdx]
+	if chk == nil {
+		return
+	}
+	rows := chk.NumRows()
+	if rows < l.dedup.opts.MinBlockRows || rows > l.dedup.opts.MaxBlockRows {
+		return
+	}
+
+	fp, encoded := l.fingerprintChunk(chk)
+	for _, candidateIdx := range l.dedup.index[fp] {
+		candidate := l.chunks[candidateIdx]
+		if candidate == nil || candidate == chk {
+			continue
+		}
+		_, candidateEncoded := l.fingerprintChunk(candidate)
+		if !bytes.Equal(encoded, candidateEncoded) {
+			continue
+		}
+
+		// Alias: share the earlier chunk, hand this one to the freelist, and
+		// keep the already-issued RowPtrs valid since RowIdx positions
+		// line up identically for a byte-identical block. Like every other
+		// freelist chunk, it stays accounted in memTracker until allocChunk
+		// reuses it and gives its bytes back. This aliases a
+		// *Chunk across two of this same List's own slots, which is a
+		// different situation from Snapshot's cross-List sharing: the
+		// candidate isn't Retain'd, so Reset must recognize the repeated
+		// pointer itself (see the seen-chunk tracking there) rather than
+		// relying on the refcount machinery meant for Snapshot.
+		l.freelist = append(l.freelist, chk)
+		l.chunks[chkIdx] = candidate
+
+		l.dedup.hits++
+		l.dedup.bytesSaved += int64(len(encoded))
+		return
+	}
+
+	l.dedup.index[fp] = append(l.dedup.index[fp], chkIdx)
+}
+
+// fingerprintChunk hashes chk's whole encoded byte range through the Rabin
+// rolling hasher and returns its final digest, used here as a plain
+// whole-block hash rather than for boundary detection (see NewDedupList).
+func (l *List) fingerprintChunk(chk *Chunk) (uint64, []byte) {
+	encoded := NewCodec(l.fieldTypes).Encode(chk)
+	h := newRabinHasher()
+	h.Write(encoded)
+	return h.Sum64(), encoded
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/util/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDedupListAliasesIdenticalBlocks builds three two-row blocks, the first
+// two byte-identical, and checks that the second is aliased to the first
+// instead of stored separately, while the distinct third block is not.
+func TestDedupListAliasesIdenticalBlocks(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewDedupList(fieldTypes, tracker, DedupOptions{MinBlockRows: 2, AvgBlockRows: 2, MaxBlockRows: 2})
+
+	appendInt64Row(l, fieldTypes, 1)
+	appendInt64Row(l, fieldTypes, 2)
+	appendInt64Row(l, fieldTypes, 1)
+	appendInt64Row(l, fieldTypes, 2)
+	appendInt64Row(l, fieldTypes, 3)
+	appendInt64Row(l, fieldTypes, 4)
+
+	require.EqualValues(t, 1, l.DedupHits())
+	require.Greater(t, l.DedupBytesSaved(), int64(0))
+	require.Same(t, l.chunks[0], l.chunks[1], "a byte-identical block must alias the earlier chunk instead of storing a copy")
+	require.NotSame(t, l.chunks[0], l.chunks[2])
+}
+
+// TestDedupListPreservesRowPtrs asserts that aliasing a block doesn't
+// invalidate RowPtrs already handed out for rows inside it.
+func TestDedupListPreservesRowPtrs(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewDedupList(fieldTypes, tracker, DedupOptions{MinBlockRows: 2, AvgBlockRows: 2, MaxBlockRows: 2})
+
+	appendInt64Row(l, fieldTypes, 1)
+	ptr := appendInt64Row(l, fieldTypes, 2)
+	appendInt64Row(l, fieldTypes, 1)
+	appendInt64Row(l, fieldTypes, 2)
+	// This last block forces the second (1, 2) block to retire and alias.
+	appendInt64Row(l, fieldTypes, 3)
+	appendInt64Row(l, fieldTypes, 4)
+
+	require.Equal(t, int64(2), l.GetRow(ptr).GetInt64(0))
+}
+
+// TestDedupListResetAccountsAliasedChunkOnce asserts that Reset hands a
+// dedup-aliased chunk to the freelist exactly once, even though it appears at
+// more than one index in l.chunks, instead of once per aliasing slot, so the
+// freelist's chunks add up to exactly the bytes the tracker still holds.
+func TestDedupListResetAccountsAliasedChunkOnce(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewDedupList(fieldTypes, tracker, DedupOptions{MinBlockRows: 2, AvgBlockRows: 2, MaxBlockRows: 2})
+
+	// Three more (1, 2) blocks after the first all alias it, so the same
+	// *Chunk ends up referenced from four different l.chunks slots.
+	for i := 0; i < 4; i++ {
+		appendInt64Row(l, fieldTypes, 1)
+		appendInt64Row(l, fieldTypes, 2)
+	}
+	appendInt64Row(l, fieldTypes, 3)
+	appendInt64Row(l, fieldTypes, 4)
+
+	require.EqualValues(t, 3, l.DedupHits())
+	require.Same(t, l.chunks[0], l.chunks[1])
+	require.Same(t, l.chunks[0], l.chunks[2])
+	require.Same(t, l.chunks[0], l.chunks[3])
+
+	l.Reset()
+	var want int64
+	for _, chk := range l.freelist {
+		want += chk.MemoryUsage()
+	}
+	require.Equal(t, want, tracker.BytesConsumed(), "resetting a List must not hand an aliased chunk to the freelist more than once")
+}
+
+// TestDedupListResetClearsFingerprintIndex fills a dedup List with several
+// distinct blocks (so the fingerprint index accumulates entries for chunk
+// indices that no longer exist once the List is reset), resets it, and
+// refills it with fewer, shorter-lived blocks than before. Without clearing
+// l.dedup.index in Reset, a fingerprint collision with a stale entry would
+// index past the end of the new, shorter l.chunks and panic.
+func TestDedupListResetClearsFingerprintIndex(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewDedupList(fieldTypes, tracker, DedupOptions{MinBlockRows: 2, AvgBlockRows: 2, MaxBlockRows: 2})
+
+	for i := int64(0); i < 5; i++ {
+		appendInt64Row(l, fieldTypes, i*2+1)
+		appendInt64Row(l, fieldTypes, i*2+2)
+	}
+	require.EqualValues(t, 0, l.DedupHits(), "all five blocks above are distinct")
+	require.Equal(t, 5, len(l.chunks))
+
+	l.Reset()
+
+	// Repeats the content (7, 8) of the last block retired, and so indexed,
+	// before the reset (the (9, 10) block was never retired); with a stale
+	// index entry pointing at the old chkIdx 3, this would look up
+	// l.chunks[3] against a freshly-reset, much shorter l.chunks.
+	appendInt64Row(l, fieldTypes, 7)
+	appendInt64Row(l, fieldTypes, 8)
+	appendInt64Row(l, fieldTypes, 11) // retires the block above
+
+	require.EqualValues(t, 0, l.DedupHits(), "the fingerprint index must not carry stale entries across Reset")
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func newFiveRowList(t *testing.T) (*List, []*types.FieldType) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	for i := 0; i < 5; i++ {
+		appendInt64Row(l, fieldTypes, int64(i))
+	}
+	return l, fieldTypes
+}
+
+// TestListIterForward walks the whole List via First/Next and expects
+// storage order.
+func TestListIterForward(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{})
+	var got []int64
+	for it.First(); it.Valid(); it.Next() {
+		got = append(got, it.Row().GetInt64(0))
+	}
+	require.Equal(t, []int64{0, 1, 2, 3, 4}, got)
+}
+
+// TestListIterReverse walks the whole List via Last/Next with Reverse set,
+// and expects the opposite of storage order.
+func TestListIterReverse(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{Reverse: true})
+	var got []int64
+	for it.Last(); it.Valid(); it.Next() {
+		got = append(got, it.Row().GetInt64(0))
+	}
+	require.Equal(t, []int64{4, 3, 2, 1, 0}, got)
+}
+
+// TestListIterReverseBounds checks that StartPtr/EndPtr flip which bound
+// they enforce when Reverse is set: StartPtr becomes the (inclusive) high
+// end and EndPtr the (exclusive) low end of the scan.
+func TestListIterReverseBounds(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{
+		Reverse:   true,
+		StartPtr:  RowPtr{ChkIdx: 1, RowIdx: 1}, // row value 3, the high end
+		EndPtr:    RowPtr{ChkIdx: 0, RowIdx: 0}, // row value 0, excluded
+		HasEndPtr: true,
+	})
+	var got []int64
+	for it.Last(); it.Valid(); it.Next() {
+		got = append(got, it.Row().GetInt64(0))
+	}
+	require.Equal(t, []int64{3, 2, 1}, got)
+}
+
+// TestListIterReversePrevMirrorsNext checks that Prev moves the opposite way
+// from Next under Reverse, i.e. in storage order.
+func TestListIterReversePrevMirrorsNext(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{Reverse: true})
+	require.True(t, it.Last())
+	require.Equal(t, int64(4), it.Row().GetInt64(0))
+	require.True(t, it.Next())
+	require.Equal(t, int64(3), it.Row().GetInt64(0))
+	require.True(t, it.Prev())
+	require.Equal(t, int64(4), it.Row().GetInt64(0))
+}
+
+// TestListIterSeekGE checks that SeekGE positions the iterator on the first
+// row at or after ptr and that Next continues forward from there.
+func TestListIterSeekGE(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{})
+	require.True(t, it.SeekGE(RowPtr{ChkIdx: 1, RowIdx: 1})) // row value 3
+	var got []int64
+	for ; it.Valid(); it.Next() {
+		got = append(got, it.Row().GetInt64(0))
+	}
+	require.Equal(t, []int64{3, 4}, got)
+}
+
+// TestListIterSeekGEOutOfRange checks that seeking past the last chunk
+// reports an invalid position instead of panicking.
+func TestListIterSeekGEOutOfRange(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{})
+	require.False(t, it.SeekGE(RowPtr{ChkIdx: 10, RowIdx: 0}))
+}
+
+// appendTwoInt64Row appends a two-column row, used by the ProjectCols tests
+// below where a single-column List can't show reordering/subsetting.
+func appendTwoInt64Row(l *List, fieldTypes []*types.FieldType, a, b int64) RowPtr {
+	chk := New(fieldTypes, 1, 1)
+	chk.AppendInt64(0, a)
+	chk.AppendInt64(1, b)
+	return l.AppendRow(chk.GetRow(0))
+}
+
+// TestListIterProjectCols checks that Row() limits its result to the
+// requested columns, in the given order.
+func TestListIterProjectCols(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(2)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	for i := int64(0); i < 4; i++ {
+		appendTwoInt64Row(l, fieldTypes, i, i*10)
+	}
+
+	it := l.NewIter(ListIterOptions{ProjectCols: []int{1}})
+	var got []int64
+	for it.First(); it.Valid(); it.Next() {
+		got = append(got, it.Row().GetInt64(0))
+	}
+	require.Equal(t, []int64{0, 10, 20, 30}, got)
+}
+
+// TestListIterNextChunkProjectCols checks that NextChunk's batched fast-path
+// honors opts.ProjectCols the same way Row() does, instead of silently
+// returning the unprojected chunk.
+func TestListIterNextChunkProjectCols(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(2)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	for i := int64(0); i < 4; i++ {
+		appendTwoInt64Row(l, fieldTypes, i, i*10)
+	}
+
+	it := l.NewIter(ListIterOptions{ProjectCols: []int{1}})
+	var got []int64
+	for chk := it.NextChunk(); chk != nil; chk = it.NextChunk() {
+		for i := 0; i < chk.NumRows(); i++ {
+			got = append(got, chk.GetRow(i).GetInt64(0))
+		}
+	}
+	require.Equal(t, []int64{0, 10, 20, 30}, got, "NextChunk must honor ProjectCols the same way Row() does")
+}
+
+// TestListIterNextChunkReverse checks the batched fast-path also respects
+// Reverse, yielding whole chunks back to front.
+func TestListIterNextChunkReverse(t *testing.T) {
+	l, _ := newFiveRowList(t)
+
+	it := l.NewIter(ListIterOptions{Reverse: true})
+	var gotChunks int
+	var got []int64
+	for chk := it.NextChunk(); chk != nil; chk = it.NextChunk() {
+		gotChunks++
+		for i := 0; i < chk.NumRows(); i++ {
+			got = append(got, chk.GetRow(i).GetInt64(0))
+		}
+	}
+	require.Equal(t, l.NumChunks(), gotChunks)
+	require.Equal(t, []int64{4, 2, 3, 0, 1}, got, "chunks come back last-to-first, each still in its own forward row order")
+}
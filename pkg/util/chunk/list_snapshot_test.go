@@ -0,0 +1,140 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/util/chunk/spill"
+	"github.com/pingcap/tidb/pkg/util/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListSnapshotPinsSharedChunks asserts that a chunk retained by a live
+// Snapshot survives the original owner's Reset instead of going to its
+// freelist.
+func TestListSnapshotPinsSharedChunks(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	for i := 0; i < 4; i++ {
+		appendInt64Row(l, fieldTypes, int64(i))
+	}
+
+	snap := l.Snapshot()
+	require.Equal(t, l.Len(), snap.Len())
+	retained := snap.chunks[0]
+
+	l.Reset()
+	require.True(t, shared(retained), "a chunk still referenced by a live snapshot must not be recyclable by its original owner")
+	require.Empty(t, l.freelist, "a shared chunk must not land on the original owner's freelist")
+
+	require.NoError(t, snap.Close())
+	require.False(t, shared(retained), "Close must release every chunk the snapshot retained")
+}
+
+// TestListSnapshotCloseReleasesTracker asserts that a Snapshot's own tracker
+// accounts for the chunks it retains, and drops that accounting once Close
+// runs.
+func TestListSnapshotCloseReleasesTracker(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	for i := 0; i < 4; i++ {
+		appendInt64Row(l, fieldTypes, int64(i))
+	}
+
+	snap := l.Snapshot()
+	require.Greater(t, snap.GetMemTracker().BytesConsumed(), int64(0))
+
+	require.NoError(t, snap.Close())
+	require.Zero(t, snap.GetMemTracker().BytesConsumed())
+}
+
+// TestListSnapshotDedupListAccountsAliasedChunkOnce asserts that Snapshot
+// charges a dedup-aliased chunk's memory to snap.memTracker exactly once,
+// even though it appears at more than one index in l.chunks, instead of
+// once per aliasing slot.
+func TestListSnapshotDedupListAccountsAliasedChunkOnce(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewDedupList(fieldTypes, tracker, DedupOptions{MinBlockRows: 2, AvgBlockRows: 2, MaxBlockRows: 2})
+
+	// Three more (1, 2) blocks after the first all alias it, so the same
+	// *Chunk ends up referenced from four different l.chunks slots; the
+	// trailing row retires the fourth block so its dedup check runs.
+	for i := 0; i < 4; i++ {
+		appendInt64Row(l, fieldTypes, 1)
+		appendInt64Row(l, fieldTypes, 2)
+	}
+	appendInt64Row(l, fieldTypes, 3)
+	require.EqualValues(t, 3, l.DedupHits())
+	require.Same(t, l.chunks[0], l.chunks[1])
+
+	// The snapshot holds two distinct chunks: the aliased (1, 2) block and
+	// the live chunk holding the trailing row.
+	snap := l.Snapshot()
+	require.Equal(t, l.chunks[0].MemoryUsage()+l.chunks[4].MemoryUsage(), snap.GetMemTracker().BytesConsumed(),
+		"an aliased chunk must be Retain'd/accounted for once per distinct chunk, not once per slot referencing it")
+
+	require.NoError(t, snap.Close())
+}
+
+// TestListSnapshotOverSpillListRehydratesEvictedChunks asserts that Snapshot
+// doesn't copy a spill-backed List's nil, evicted l.chunks entries verbatim:
+// every row must still be readable from the snapshot even after the
+// original chunk holding it was spilled to disk.
+func TestListSnapshotOverSpillListRehydratesEvictedChunks(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	const numRows = 64
+	ptrs := make([]RowPtr, 0, numRows)
+	for i := 0; i < numRows; i++ {
+		ptrs = append(ptrs, appendInt64Row(l, fieldTypes, int64(i)))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) > 0
+	}, time.Second, time.Millisecond, "spillDiskAction must eventually evict at least one chunk once softLimit is crossed")
+
+	snap := l.Snapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+
+	for _, chk := range snap.chunks {
+		require.NotNil(t, chk, "Snapshot must rehydrate every chunk l had evicted, not copy the nil placeholder")
+	}
+	for i, ptr := range ptrs {
+		require.Equal(t, int64(i), snap.GetRow(ptr).GetInt64(0))
+	}
+}
+
+// TestListSnapshotCloseIsNoopForPlainList asserts Close on a List that
+// wasn't produced by Snapshot does nothing destructive.
+func TestListSnapshotCloseIsNoopForPlainList(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l := NewListWithMemTracker(fieldTypes, 2, 2, tracker)
+	appendInt64Row(l, fieldTypes, 1)
+
+	require.NoError(t, l.Close())
+	require.Equal(t, 1, l.Len())
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/chunk/spill"
+	"github.com/pingcap/tidb/pkg/util/memory"
+)
+
+// rehydrateCacheCapacity bounds how many spilled chunks NewListWithSpill
+// keeps rehydrated in memory at once; the rest are decoded on demand and
+// dropped again once the cache is full.
+const rehydrateCacheCapacity = 4
+
+// spillListSeq hands out the directory suffix NewListWithSpill uses for
+// each List. A monotonic counter, rather than the List's own pointer, is
+// used on purpose: a *List address can be reused by the allocator once a
+// previous List is garbage collected, which would make a pointer-derived
+// directory name collide with a prior, never-cleaned-up MANIFEST.
+var spillListSeq int64
+
+// NewListWithSpill creates a List that spills fully-consumed chunks to an
+// on-disk backend once memTracker's consumption crosses softLimit bytes.
+// backend is opened against a fresh subdirectory of dir unique to this List,
+// so two Lists (and therefore two executors) never collide; pass nil to use
+// the bundled spill.BadgerEmbeddedBackend. softLimit is installed as
+// tracker's bytes limit, and the spill action replaces tracker's
+// action-on-exceed.
+//
+// Callers must call List.Close once they're done with a spill-backed List,
+// so the backend and its directory are actually cleaned up; Reset only
+// clears which chunks are currently spilled, it doesn't tear the backend
+// down, since the List is expected to keep being used afterwards.
+func NewListWithSpill(fieldTypes []*types.FieldType, initChunkSize, maxChunkSize int, tracker *memory.Tracker, dir string, softLimit int64, backend spill.Backend) (*List, error) {
+	l := NewListWithMemTracker(fieldTypes, initChunkSize, maxChunkSize, tracker)
+	if backend == nil {
+		backend = spill.NewBadgerEmbeddedBackend()
+	}
+	id := atomic.AddInt64(&spillListSeq, 1)
+	listDir := filepath.Join(dir, fmt.Sprintf("list-%d", id))
+	if err := backend.Open(listDir); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	l.spillBackend = backend
+	l.spillDir = listDir
+	l.softLimit = softLimit
+	l.evicted = make(map[uint32]struct{})
+	l.rehydrated = make(map[uint32]*Chunk)
+	// The tracker only fires its action once it has a limit to exceed.
+	l.memTracker.SetBytesLimit(softLimit)
+	l.memTracker.SetActionOnExceed(&spillDiskAction{list: l})
+	return l, nil
+}
+
+// spillDiskAction is the memory.ActionOnExceed fired once the List's tracker
+// crosses its soft limit. Action itself must return immediately: Consume
+// calls it synchronously on whichever goroutine is in the middle of
+// AppendRow, and the actual eviction work (chunk encoding, backend.Put) is
+// blocking disk I/O that must never run on that hot path. Action only
+// kicks off spillOldestConsumedChunks on a dedicated goroutine, guarded so
+// at most one spill runs at a time.
+type spillDiskAction struct {
+	memory.BaseOOMAction
+	list *List
+}
+
+// Action implements memory.ActionOnExceed.
+func (a *spillDiskAction) Action(t *memory.Tracker) {
+	if atomic.CompareAndSwapInt32(&a.list.spilling, 0, 1) {
+		go a.list.runSpillAsync()
+	}
+	if fallback := a.GetFallback(); fallback != nil {
+		fallback.Action(t)
+	}
+}
+
+// GetPriority implements memory.ActionOnExceed.
+func (*spillDiskAction) GetPriority() int64 {
+	return memory.DefSpillPriority
+}
+
+// runSpillAsync runs spillOldestConsumedChunks off the caller's goroutine
+// and clears the "a spill is already in flight" flag when done.
+func (l *List) runSpillAsync() {
+	defer atomic.StoreInt32(&l.spilling, 0)
+	l.spillMu.Lock()
+	defer l.spillMu.Unlock()
+	l.spillOldestConsumedChunks()
+}
+
+// spillOldestConsumedChunks serializes and evicts chunks at or before
+// consumedIdx, oldest first, until the tracker reports it is back under
+// softLimit or there is nothing left worth evicting. Callers must hold
+// spillMu, since this mutates l.chunks/l.evicted concurrently with
+// AppendRow's own chunk bookkeeping.
+func (l *List) spillOldestConsumedChunks() {
+	if l.spillBackend == nil {
+		return
+	}
+	codec := NewCodec(l.fieldTypes)
+	for i := 0; i <= l.consumedIdx; i++ {
+		if l.memTracker.BytesConsumed() <= l.softLimit {
+			return
+		}
+		idx := uint32(i)
+		if _, ok := l.evicted[idx]; ok {
+			continue
+		}
+		chk := l.chunks[i]
+		if chk == nil || chk.NumRows() == 0 {
+			continue
+		}
+		if shared(chk) {
+			// A live Snapshot still references this chunk (see Reset's
+			// identical check); spilling it would serialize and null out
+			// l.chunks[i] while the snapshot's in-memory copy stays fully
+			// resident, burning disk I/O and a future rehydrate round-trip
+			// for zero memory benefit.
+			continue
+		}
+
+		buf := codec.Encode(chk)
+		if err := l.spillBackend.Put(uint64(idx), buf); err != nil {
+			// Leave the chunk in memory; it will be retried on the next
+			// action firing.
+			continue
+		}
+
+		l.memTracker.Consume(-chk.MemoryUsage())
+		l.evicted[idx] = struct{}{}
+		l.chunks[i] = nil
+	}
+}
+
+// rehydrate loads an evicted chunk back into memory, paging it into a small
+// LRU-managed cache so repeated GetRow/Walk access doesn't re-decode on
+// every call. The rehydrated bytes are added back to memTracker, since
+// they're real heap memory again and must be able to re-trigger the soft
+// limit action.
+func (l *List) rehydrate(chkIdx uint32) (*Chunk, error) {
+	l.spillMu.Lock()
+	defer l.spillMu.Unlock()
+
+	if chk, ok := l.rehydrated[chkIdx]; ok {
+		l.touchRehydrated(chkIdx)
+		return chk, nil
+	}
+
+	buf, err := l.spillBackend.Get(uint64(chkIdx))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	codec := NewCodec(l.fieldTypes)
+	chk, err := codec.Decode(buf)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if len(l.rehydrated) >= rehydrateCacheCapacity {
+		// rehydrateOrder's front entry is the true least-recently-used one:
+		// touchRehydrated moves an index to the back on every hit, so
+		// whatever is still at the front hasn't been accessed since before
+		// everything else currently cached.
+		oldest := l.rehydrateOrder[0]
+		l.rehydrateOrder = l.rehydrateOrder[1:]
+		l.memTracker.Consume(-l.rehydrated[oldest].MemoryUsage())
+		delete(l.rehydrated, oldest)
+	}
+	l.rehydrated[chkIdx] = chk
+	l.rehydrateOrder = append(l.rehydrateOrder, chkIdx)
+	l.memTracker.Consume(chk.MemoryUsage())
+	return chk, nil
+}
+
+// touchRehydrated marks chkIdx as the most recently used entry in the
+// rehydrate cache, moving it to the back of rehydrateOrder. Callers must
+// hold spillMu.
+func (l *List) touchRehydrated(chkIdx uint32) {
+	for i, idx := range l.rehydrateOrder {
+		if idx == chkIdx {
+			l.rehydrateOrder = append(l.rehydrateOrder[:i], l.rehydrateOrder[i+1:]...)
+			break
+		}
+	}
+	l.rehydrateOrder = append(l.rehydrateOrder, chkIdx)
+}
+
+// getChunk returns the chunk at chkIdx, rehydrating it from the spill
+// backend first if it was evicted.
+func (l *List) getChunk(chkIdx uint32) (*Chunk, error) {
+	if l.spillBackend == nil {
+		return l.chunks[chkIdx], nil
+	}
+	l.spillMu.Lock()
+	chk := l.chunks[chkIdx]
+	l.spillMu.Unlock()
+	if chk != nil {
+		return chk, nil
+	}
+	return l.rehydrate(chkIdx)
+}
+
+// purgeSpill drops this List's record of which chunks are currently
+// spilled. It is called from Reset/Clear, which empty l.chunks but expect
+// the backend and its directory to keep serving the List afterwards.
+// Callers must hold l.spillMu, for the same reason Reset/Clear take it
+// around the rest of their chunk bookkeeping.
+func (l *List) purgeSpill() {
+	if l.spillBackend == nil {
+		return
+	}
+	for idx := range l.evicted {
+		_ = l.spillBackend.Delete(uint64(idx))
+	}
+	clear(l.evicted)
+	for idx, chk := range l.rehydrated {
+		l.memTracker.Consume(-chk.MemoryUsage())
+		delete(l.rehydrated, idx)
+	}
+	l.rehydrateOrder = l.rehydrateOrder[:0]
+}
+
+// dropSpill closes the spill backend and removes its directory entirely.
+// Unlike purgeSpill, this is only safe to call once the List itself is
+// done being used.
+func (l *List) dropSpill() error {
+	if l.spillBackend == nil {
+		return nil
+	}
+	if err := l.spillBackend.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.RemoveAll(l.spillDir))
+}
@@ -0,0 +1,258 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/chunk/spill"
+	"github.com/pingcap/tidb/pkg/util/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func newLonglongFieldTypes(n int) []*types.FieldType {
+	fts := make([]*types.FieldType, n)
+	for i := range fts {
+		fts[i] = types.NewFieldType(mysql.TypeLonglong)
+	}
+	return fts
+}
+
+func appendInt64Row(l *List, fieldTypes []*types.FieldType, v int64) RowPtr {
+	chk := New(fieldTypes, 1, 1)
+	chk.AppendInt64(0, v)
+	return l.AppendRow(chk.GetRow(0))
+}
+
+// TestListSpillRoundTrip drives enough rows through a spill-backed List to
+// cross softLimit, waits for the async spill goroutine to evict at least one
+// chunk, then confirms every row is still readable (transparently
+// rehydrated) with its original value intact.
+func TestListSpillRoundTrip(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	const numRows = 64
+	ptrs := make([]RowPtr, 0, numRows)
+	for i := 0; i < numRows; i++ {
+		ptrs = append(ptrs, appendInt64Row(l, fieldTypes, int64(i)))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) > 0
+	}, time.Second, time.Millisecond, "spillDiskAction must eventually evict at least one chunk once softLimit is crossed")
+
+	for i, ptr := range ptrs {
+		row := l.GetRow(ptr)
+		require.Equal(t, int64(i), row.GetInt64(0))
+	}
+}
+
+// TestListSpillWalkCrossesEvictedChunks drives enough rows through a
+// spill-backed List to evict at least one chunk, then walks the whole List
+// via Walk (which goes through ListIter's NumRowsOfChunk/getChunk, not just
+// GetRow) and confirms it doesn't panic on a nil, evicted l.chunks entry and
+// yields every row in order.
+func TestListSpillWalkCrossesEvictedChunks(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	const numRows = 64
+	for i := 0; i < numRows; i++ {
+		appendInt64Row(l, fieldTypes, int64(i))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) > 0
+	}, time.Second, time.Millisecond, "spillDiskAction must eventually evict at least one chunk once softLimit is crossed")
+
+	var got []int64
+	require.NoError(t, l.Walk(func(row Row) error {
+		got = append(got, row.GetInt64(0))
+		return nil
+	}))
+
+	want := make([]int64, numRows)
+	for i := range want {
+		want[i] = int64(i)
+	}
+	require.Equal(t, want, got)
+}
+
+// TestListSpillRehydrateTracksMemory asserts that paging a chunk back in via
+// GetRow re-adds its bytes to memTracker, so a soft-limit action can fire
+// again for memory that has genuinely come back onto the heap.
+func TestListSpillRehydrateTracksMemory(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	const numRows = 64
+	ptrs := make([]RowPtr, 0, numRows)
+	for i := 0; i < numRows; i++ {
+		ptrs = append(ptrs, appendInt64Row(l, fieldTypes, int64(i)))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) > 0
+	}, time.Second, time.Millisecond)
+
+	before := tracker.BytesConsumed()
+	l.GetRow(ptrs[0])
+	require.Greater(t, tracker.BytesConsumed(), before, "rehydrating an evicted chunk must add its bytes back to memTracker")
+}
+
+// TestListSpillResetReleasesRehydratedMemory asserts that Reset gives back
+// the bytes a prior rehydrate charged to memTracker, instead of leaking them
+// on every reuse of a spill-backed List in a Reset loop. Chunks Reset hands
+// to the freelist stay accounted, so afterwards the tracker holds exactly
+// the freelist's bytes.
+func TestListSpillResetReleasesRehydratedMemory(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	const numRows = 64
+	ptrs := make([]RowPtr, 0, numRows)
+	for i := 0; i < numRows; i++ {
+		ptrs = append(ptrs, appendInt64Row(l, fieldTypes, int64(i)))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) > 0
+	}, time.Second, time.Millisecond)
+
+	l.GetRow(ptrs[0]) // rehydrates a chunk, charging its bytes to tracker
+
+	l.Reset()
+	var want int64
+	for _, chk := range l.freelist {
+		want += chk.MemoryUsage()
+	}
+	require.Equal(t, want, tracker.BytesConsumed(), "Reset must release rehydrated chunks' bytes, leaving only the freelist's chunks accounted")
+}
+
+// TestListSpillSkipsSharedChunks asserts that a chunk still pinned by a live
+// Snapshot is never handed to spillOldestConsumedChunks, even once it is
+// otherwise eligible (consumed, over softLimit): spilling it would serialize
+// and null out l.chunks[i] while the snapshot keeps its own in-memory copy
+// resident, burning disk I/O for zero memory benefit.
+func TestListSpillSkipsSharedChunks(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	appendInt64Row(l, fieldTypes, 1)
+	appendInt64Row(l, fieldTypes, 2)
+	appendInt64Row(l, fieldTypes, 3)
+	appendInt64Row(l, fieldTypes, 4)
+	pinned := l.chunks[0]
+
+	snap := l.Snapshot()
+	defer func() { require.NoError(t, snap.Close()) }()
+
+	for i := 0; i < 64; i++ {
+		appendInt64Row(l, fieldTypes, int64(i))
+	}
+
+	require.Never(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		_, ok := l.evicted[0]
+		return ok
+	}, 200*time.Millisecond, 10*time.Millisecond, "a chunk pinned by a live Snapshot must never be spilled")
+	require.True(t, shared(pinned))
+}
+
+// TestListSpillRehydrateCacheIsTrueLRU fills the rehydrate cache to capacity,
+// re-accesses every entry but the oldest (so it alone is now the true least-
+// recently-used one), then forces one more eviction and confirms it is the
+// untouched entry that gets dropped, not an arbitrary one.
+func TestListSpillRehydrateCacheIsTrueLRU(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	l, err := NewListWithSpill(fieldTypes, 1, 1, tracker, t.TempDir(), 1, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, l.Close()) }()
+
+	// One row per chunk: chunks 0..rehydrateCacheCapacity are all consumed
+	// and spilled, and only the trailing live chunk stays in memory.
+	const numRows = rehydrateCacheCapacity + 2
+	ptrs := make([]RowPtr, 0, numRows)
+	for i := 0; i < numRows; i++ {
+		ptrs = append(ptrs, appendInt64Row(l, fieldTypes, int64(i)))
+	}
+
+	require.Eventually(t, func() bool {
+		l.spillMu.Lock()
+		defer l.spillMu.Unlock()
+		return len(l.evicted) >= numRows-1
+	}, time.Second, time.Millisecond, "every fully-consumed chunk but the last must eventually be spilled")
+
+	// Rehydrate chunks 0..capacity-1, filling the cache, then re-touch every
+	// one of them except chunk 0 so chunk 0 becomes the true LRU entry.
+	for i := 0; i < rehydrateCacheCapacity; i++ {
+		l.GetRow(ptrs[i])
+	}
+	for i := 1; i < rehydrateCacheCapacity; i++ {
+		l.GetRow(ptrs[i])
+	}
+
+	// One more rehydrate forces an eviction; it must drop chunk 0, the only
+	// entry that hasn't been touched since it was first cached.
+	l.GetRow(ptrs[rehydrateCacheCapacity])
+
+	l.spillMu.Lock()
+	_, stillCached := l.rehydrated[0]
+	l.spillMu.Unlock()
+	require.False(t, stillCached, "the true least-recently-used entry must be the one evicted")
+}
+
+// TestListSpillCloseRemovesBackend confirms Close both closes the backend
+// and removes its directory, so a spill-backed List leaves nothing behind.
+func TestListSpillCloseRemovesBackend(t *testing.T) {
+	fieldTypes := newLonglongFieldTypes(1)
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	dir := t.TempDir()
+	l, err := NewListWithSpill(fieldTypes, 4, 4, tracker, dir, 1<<30, spill.NewBadgerEmbeddedBackend())
+	require.NoError(t, err)
+
+	spillDir := l.spillDir
+	require.NoError(t, l.Close())
+	require.NoDirExists(t, spillDir)
+}
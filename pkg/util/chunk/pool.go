@@ -0,0 +1,227 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/memory"
+)
+
+// shapeKey identifies chunks that are interchangeable for pooling purposes.
+// It must capture the full schema, not just the column count: a pooled
+// chunk's underlying column buffers are laid out for its concrete field
+// types (fixed-width vs. variable-length, element size, etc.), so handing a
+// chunk to a List with a different schema but the same column count would
+// let that List misinterpret its buffers.
+type shapeKey struct {
+	schema   string
+	capacity int
+}
+
+func shapeOf(fieldTypes []*types.FieldType, capacity int) shapeKey {
+	parts := make([]string, len(fieldTypes))
+	for i, ft := range fieldTypes {
+		parts[i] = ft.String()
+	}
+	return shapeKey{schema: strings.Join(parts, "|"), capacity: capacity}
+}
+
+// defaultPoolHotCapacity bounds how many chunks, across all shapes, a Pool
+// created with NewPool keeps in its hot tier before admitting a new shape
+// means evicting an existing one instead of growing without bound.
+const defaultPoolHotCapacity = 256
+
+// Pool is a shared, process-wide chunk pool. Unlike a List's own private
+// freelist, a Pool is meant to be reused across many Lists of similar shape
+// so that repeated large allocations and the resulting fragmentation don't
+// pile up when many concurrent queries churn Lists of the same column
+// count and capacity.
+//
+// Returned chunks aren't kept hot unconditionally: a small TinyLFU-style
+// admission filter decides whether a shape has been returned often enough
+// recently to be worth hot-tier memory, or whether it should instead be
+// compressed into a cold tier (or dropped) to make room for hotter shapes.
+// The hot tier itself is capacity-bounded: once full, admitting a new shape
+// means evicting the coldest current hot shape (by the same frequency
+// sketch), and only if the newcomer is actually hotter than that victim.
+type Pool struct {
+	mu         sync.Mutex
+	hot        map[shapeKey][]*Chunk
+	hotCount   int
+	cold       map[shapeKey][][]byte
+	shapeTypes map[shapeKey][]*types.FieldType // fieldTypes last seen for a shape, needed to demote a hot victim to cold.
+
+	admission   *tinyLFU
+	tracker     *memory.Tracker // accounts for bytes held in the hot tier only.
+	compressor  ChunkCompressor
+	hotCapacity int // max chunks, across all shapes, kept in the hot tier at once.
+}
+
+// NewPool creates an empty Pool with the default hot-tier capacity. tracker
+// accounts for memory held by chunks currently sitting in the pool's hot
+// tier; it is independent of any individual List's own tracker.
+func NewPool(tracker *memory.Tracker) *Pool {
+	return NewPoolWithHotCapacity(tracker, defaultPoolHotCapacity)
+}
+
+// NewPoolWithHotCapacity is NewPool with an explicit cap on how many
+// chunks, summed across every shape, the hot tier may hold at once.
+func NewPoolWithHotCapacity(tracker *memory.Tracker, hotCapacity int) *Pool {
+	return &Pool{
+		hot:         make(map[shapeKey][]*Chunk),
+		cold:        make(map[shapeKey][][]byte),
+		shapeTypes:  make(map[shapeKey][]*types.FieldType),
+		admission:   newTinyLFU(),
+		tracker:     tracker,
+		compressor:  SnappyChunkCompressor{},
+		hotCapacity: hotCapacity,
+	}
+}
+
+// Get returns a reset, ready-to-use chunk of the given shape, or nil if the
+// pool has nothing to offer and the caller should fall back to New/Renew.
+func (p *Pool) Get(fieldTypes []*types.FieldType, capacity int) *Chunk {
+	key := shapeOf(fieldTypes, capacity)
+
+	p.mu.Lock()
+	if stack := p.hot[key]; len(stack) > 0 {
+		chk := stack[len(stack)-1]
+		p.hot[key] = stack[:len(stack)-1]
+		p.hotCount--
+		p.mu.Unlock()
+
+		p.tracker.Consume(-chk.MemoryUsage())
+		poolHitTotal.Inc()
+		chk.Reset()
+		return chk
+	}
+
+	blobs := p.cold[key]
+	if len(blobs) == 0 {
+		p.mu.Unlock()
+		poolMissTotal.Inc()
+		return nil
+	}
+	blob := blobs[len(blobs)-1]
+	p.cold[key] = blobs[:len(blobs)-1]
+	p.mu.Unlock()
+
+	raw, err := p.compressor.Decompress(blob)
+	if err != nil {
+		// A corrupted cold-tier entry isn't something Get's caller can act
+		// on beyond falling back to a fresh chunk, so don't propagate it as
+		// an error — but don't risk handing back a garbage chunk either.
+		poolMissTotal.Inc()
+		return nil
+	}
+	chk, err := NewCodec(fieldTypes).Decode(raw)
+	if err != nil {
+		poolMissTotal.Inc()
+		return nil
+	}
+	chk.Reset()
+	poolPromoteTotal.Inc()
+	return chk
+}
+
+// Put offers a fully-consumed chunk back to the pool. The admission filter
+// decides whether it earns hot-tier memory; if not, it is compressed into
+// the cold tier instead of being freed outright, so a shape that later
+// becomes popular again doesn't have to pay a decompression-free allocation
+// from scratch.
+//
+// Once the hot tier is at hotCapacity, admitting this chunk means evicting
+// the current hot shape with the lowest recent observation count, and only
+// if this shape has been observed at least as often — the standard
+// (W-)TinyLFU admit-vs-evict comparison, so a newcomer that's merely
+// crossed the threshold once can't displace a shape that's still hotter.
+// If it loses that comparison, this chunk goes cold instead.
+func (p *Pool) Put(fieldTypes []*types.FieldType, capacity int, chk *Chunk) {
+	key := shapeOf(fieldTypes, capacity)
+
+	if p.admission.admit(key) {
+		p.mu.Lock()
+		p.shapeTypes[key] = fieldTypes
+
+		if p.hotCount < p.hotCapacity {
+			p.hot[key] = append(p.hot[key], chk)
+			p.hotCount++
+			p.mu.Unlock()
+			p.tracker.Consume(chk.MemoryUsage())
+			poolPromoteTotal.Inc()
+			return
+		}
+
+		victimKey, victimChk, victimTypes, ok := p.popHotVictimLocked(key)
+		if !ok {
+			p.mu.Unlock()
+			p.demoteToCold(key, fieldTypes, chk)
+			return
+		}
+		p.hot[key] = append(p.hot[key], chk)
+		p.mu.Unlock()
+
+		p.tracker.Consume(-victimChk.MemoryUsage())
+		p.demoteToCold(victimKey, victimTypes, victimChk)
+		p.tracker.Consume(chk.MemoryUsage())
+		poolPromoteTotal.Inc()
+		return
+	}
+
+	p.demoteToCold(key, fieldTypes, chk)
+}
+
+// popHotVictimLocked finds the current hot shape with the lowest recent
+// observation count, pops one chunk from it, and reports it — but only if
+// key itself has been observed at least as often recently; otherwise ok is
+// false and the caller should send key's own chunk cold instead of
+// displacing a shape that's still hotter than it. p.mu must be held.
+func (p *Pool) popHotVictimLocked(key shapeKey) (victim shapeKey, chk *Chunk, fieldTypes []*types.FieldType, ok bool) {
+	victimFreq := uint32(0)
+	found := false
+	for k, stack := range p.hot {
+		if len(stack) == 0 {
+			continue
+		}
+		if freq := p.admission.frequency(k); !found || freq < victimFreq {
+			victim, victimFreq, found = k, freq, true
+		}
+	}
+	if !found || p.admission.frequency(key) < victimFreq {
+		return shapeKey{}, nil, nil, false
+	}
+	stack := p.hot[victim]
+	chk = stack[len(stack)-1]
+	p.hot[victim] = stack[:len(stack)-1]
+	return victim, chk, p.shapeTypes[victim], true
+}
+
+// demoteToCold compresses chk (of the given shape) into the cold tier. It
+// must not be called with p.mu held, since Compress does real work.
+func (p *Pool) demoteToCold(key shapeKey, fieldTypes []*types.FieldType, chk *Chunk) {
+	raw := NewCodec(fieldTypes).Encode(chk)
+	blob, err := p.compressor.Compress(raw)
+	if err != nil {
+		poolEvictTotal.Inc()
+		return
+	}
+	p.mu.Lock()
+	p.cold[key] = append(p.cold[key], blob)
+	p.mu.Unlock()
+	poolEvictTotal.Inc()
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import "sync"
+
+// tinyLFUAdmitThreshold is how many times a shape must have been returned
+// recently (since the last halving) before Put is allowed to place it in
+// the hot tier instead of the cold one.
+const tinyLFUAdmitThreshold = 4
+
+// tinyLFUResetThreshold bounds how many total observations tinyLFU
+// accumulates before it halves every counter, so the filter tracks recent
+// popularity instead of all-time popularity.
+const tinyLFUResetThreshold = 10000
+
+// tinyLFU is a deliberately small, Ristretto/TinyLFU-style admission
+// filter: a per-shape frequency counter with periodic aging. It answers one
+// question — "has this shape been returned often enough lately to deserve
+// hot-tier memory?" — without the doorkeeper bloom filter or sketch-based
+// counter compression a full TinyLFU implementation would use, since the
+// key space here (distinct chunk shapes) is orders of magnitude smaller
+// than the key space Ristretto was built for.
+type tinyLFU struct {
+	mu       sync.Mutex
+	counters map[shapeKey]uint32
+	total    uint32
+}
+
+func newTinyLFU() *tinyLFU {
+	return &tinyLFU{counters: make(map[shapeKey]uint32)}
+}
+
+// admit records an observation of key and reports whether it has crossed
+// the admission threshold.
+func (f *tinyLFU) admit(key shapeKey) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.counters[key]++
+	f.total++
+	if f.total > tinyLFUResetThreshold {
+		for k, c := range f.counters {
+			f.counters[k] = c / 2
+		}
+		f.total /= 2
+	}
+	return f.counters[key] >= tinyLFUAdmitThreshold
+}
+
+// frequency reports key's current observation count, without recording a
+// new observation. Used to compare an admission candidate against the
+// current hot-tier victim.
+func (f *tinyLFU) frequency(key shapeKey) uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counters[key]
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"github.com/golang/snappy"
+	"github.com/pingcap/errors"
+)
+
+// ChunkCompressor is the codec a Pool uses to shrink cold-tier chunks. It is
+// a narrow interface so the bundled Snappy implementation can be swapped
+// for another codec without touching Pool itself.
+type ChunkCompressor interface {
+	Compress(raw []byte) ([]byte, error)
+	Decompress(compressed []byte) ([]byte, error)
+}
+
+// SnappyChunkCompressor is the default ChunkCompressor. Snappy trades
+// compression ratio for speed, which matters here: Put's admission miss
+// path (and Get's cold-tier hit path) runs compression/decompression
+// directly on the hot allocation path of query execution, so a codec
+// that's merely small loses to one that's fast.
+type SnappyChunkCompressor struct{}
+
+// Compress implements ChunkCompressor.
+func (SnappyChunkCompressor) Compress(raw []byte) ([]byte, error) {
+	return snappy.Encode(nil, raw), nil
+}
+
+// Decompress implements ChunkCompressor.
+func (SnappyChunkCompressor) Decompress(compressed []byte) ([]byte, error) {
+	raw, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	poolHitTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "tidb",
+		Subsystem: "chunk_pool",
+		Name:      "hits_total",
+		Help:      "Counter of chunk.Pool.Get calls served from the hot tier.",
+	})
+	poolMissTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "tidb",
+		Subsystem: "chunk_pool",
+		Name:      "misses_total",
+		Help:      "Counter of chunk.Pool.Get calls that found nothing usable, hot or cold.",
+	})
+	poolPromoteTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "tidb",
+		Subsystem: "chunk_pool",
+		Name:      "promotions_total",
+		Help:      "Counter of chunks admitted to, or decompressed back into, the hot tier.",
+	})
+	poolEvictTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "tidb",
+		Subsystem: "chunk_pool",
+		Name:      "evictions_total",
+		Help:      "Counter of chunks rejected from the hot tier and compressed into the cold tier (or dropped on compression failure).",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(poolHitTotal, poolMissTotal, poolPromoteTotal, poolEvictTotal)
+}
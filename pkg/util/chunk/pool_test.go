@@ -0,0 +1,116 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	"github.com/pingcap/tidb/pkg/types"
+	"github.com/pingcap/tidb/pkg/util/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPoolGetOnEmptyPool asserts that Get on a Pool with nothing stored for
+// a shape reports nil rather than, say, panicking or returning a zero-value
+// chunk.
+func TestPoolGetOnEmptyPool(t *testing.T) {
+	pool := NewPool(memory.NewTracker(memory.LabelForChunkList, -1))
+	require.Nil(t, pool.Get(newLonglongFieldTypes(1), 4))
+}
+
+// TestPoolColdTierRoundTrip exercises the cold-tier path directly: a shape
+// that hasn't crossed the admission threshold yet is compressed on Put, and
+// Get must decompress and decode it back into an equivalent chunk.
+func TestPoolColdTierRoundTrip(t *testing.T) {
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	pool := NewPool(tracker)
+	fieldTypes := newLonglongFieldTypes(1)
+	chk := New(fieldTypes, 4, 4)
+	chk.AppendInt64(0, 42)
+
+	pool.Put(fieldTypes, 4, chk) // first Put for this shape: below the admission threshold, goes cold
+
+	got := pool.Get(fieldTypes, 4)
+	require.NotNil(t, got, "Get must decompress and decode a cold-tier entry back into a usable chunk")
+	require.Zero(t, got.NumRows(), "a pooled chunk must come back reset and empty, ready for the next List to append into")
+	require.Zero(t, tracker.BytesConsumed(), "a cold-tier chunk must not be accounted against the hot-tier tracker")
+}
+
+// TestPoolHotTierAfterAdmission asserts that once a shape crosses the
+// admission threshold, Put keeps it in the hot tier (and accounted against
+// the Pool's tracker) instead of compressing it.
+func TestPoolHotTierAfterAdmission(t *testing.T) {
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	pool := NewPool(tracker)
+	fieldTypes := newLonglongFieldTypes(1)
+	chk := New(fieldTypes, 4, 4)
+
+	for i := 0; i < tinyLFUAdmitThreshold-1; i++ {
+		pool.Put(fieldTypes, 4, chk)
+	}
+	require.Zero(t, tracker.BytesConsumed(), "a shape under the admission threshold must not occupy hot-tier memory")
+
+	pool.Put(fieldTypes, 4, chk) // this Put crosses the threshold
+	require.Greater(t, tracker.BytesConsumed(), int64(0))
+
+	got := pool.Get(fieldTypes, 4)
+	require.NotNil(t, got)
+	require.Zero(t, tracker.BytesConsumed(), "Get must return the hot-tier chunk's bytes to the tracker")
+}
+
+// TestPoolHotTierCapacityEvictsColderShape asserts that once the hot tier is
+// at its configured capacity, admitting a newly-hot shape demotes the
+// current hot shape with the lowest recent observation count (here the same
+// count as the newcomer's, which is enough) to the cold tier instead of
+// growing the hot tier without bound.
+func TestPoolHotTierCapacityEvictsColderShape(t *testing.T) {
+	tracker := memory.NewTracker(memory.LabelForChunkList, -1)
+	pool := NewPoolWithHotCapacity(tracker, 1)
+
+	aTypes := newLonglongFieldTypes(1)
+	bTypes := newLonglongFieldTypes(2)
+	chkA := New(aTypes, 4, 4)
+	chkB := New(bTypes, 4, 4)
+
+	for i := 0; i < tinyLFUAdmitThreshold; i++ {
+		pool.Put(aTypes, 4, chkA)
+	}
+	require.Equal(t, 1, pool.hotCount, "the first shape to cross the admission threshold takes the single hot-tier slot")
+
+	for i := 0; i < tinyLFUAdmitThreshold; i++ {
+		pool.Put(bTypes, 4, chkB)
+	}
+	require.Equal(t, 1, pool.hotCount, "the hot tier must stay within its configured capacity")
+	require.Empty(t, pool.hot[shapeOf(aTypes, 4)], "a hot shape observed no more often than the newcomer must be demoted out of the hot tier to make room for it")
+	require.NotEmpty(t, pool.hot[shapeOf(bTypes, 4)])
+
+	require.NotNil(t, pool.Get(aTypes, 4), "a demoted hot chunk must land in the cold tier, not be dropped outright")
+}
+
+// TestPoolRejectsMismatchedSchema asserts shapeKey distinguishes chunks by
+// full schema, not just column count, so a Get for a different schema never
+// hands back a chunk laid out for another one.
+func TestPoolRejectsMismatchedSchema(t *testing.T) {
+	pool := NewPool(memory.NewTracker(memory.LabelForChunkList, -1))
+	intTypes := newLonglongFieldTypes(1)
+	chk := New(intTypes, 4, 4)
+	for i := 0; i < tinyLFUAdmitThreshold; i++ {
+		pool.Put(intTypes, 4, chk)
+	}
+
+	stringTypes := []*types.FieldType{types.NewFieldType(mysql.TypeVarchar)}
+	require.Nil(t, pool.Get(stringTypes, 4), "a pool must never hand back a chunk laid out for a different schema")
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunk
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// rabinPolynomial is the irreducible polynomial (over GF(2)) used to derive
+// the Rabin fingerprint tables below.
+const rabinPolynomial polynomial = 0x3DA3358B4DC173
+
+// rabinWindowSize is the width, in bytes, of the sliding window the rolling
+// fingerprint is computed over.
+const rabinWindowSize = 64
+
+// modTableShift is the bit offset used to pick the mod-table index out of
+// the current digest: the degree of rabinPolynomial (53) minus 8, i.e. the
+// top byte of a digest reduced mod rabinPolynomial, which is the byte that
+// overflows past the degree once the digest is shifted left by 8.
+const modTableShift = 45
+
+// polynomial represents a polynomial over GF(2), with bit i of the value
+// being the coefficient of x^i.
+type polynomial uint64
+
+func (p polynomial) deg() int {
+	if p == 0 {
+		return -1
+	}
+	return bits.Len64(uint64(p)) - 1
+}
+
+// mod computes p mod m over GF(2).
+func (p polynomial) mod(m polynomial) polynomial {
+	dm := m.deg()
+	for p.deg() >= dm {
+		p ^= m << uint(p.deg()-dm)
+	}
+	return p
+}
+
+// mulmod computes (p*x) mod m over GF(2).
+func (p polynomial) mulmod(x, m polynomial) polynomial {
+	var res polynomial
+	for i := 0; i <= x.deg(); i++ {
+		if x&(1<<uint(i)) != 0 {
+			res ^= p << uint(i)
+		}
+	}
+	return res.mod(m)
+}
+
+// rabinTables holds the precomputed mod/out tables used to slide the
+// fingerprint window one byte at a time without recomputing it from
+// scratch.
+type rabinTables struct {
+	out [256]polynomial
+	mod [256]polynomial
+}
+
+var (
+	rabinTablesOnce sync.Once
+	rabinTablesInst *rabinTables
+)
+
+func getRabinTables() *rabinTables {
+	rabinTablesOnce.Do(func() {
+		rabinTablesInst = buildRabinTables(rabinPolynomial, rabinWindowSize)
+	})
+	return rabinTablesInst
+}
+
+func buildRabinTables(p polynomial, windowSize int) *rabinTables {
+	t := &rabinTables{}
+	for b := 0; b < 256; b++ {
+		var h polynomial
+		h = appendByte(h, byte(b), p)
+		for i := 0; i < windowSize-1; i++ {
+			h = appendByte(h, 0, p)
+		}
+		t.out[b] = h
+	}
+	k := p.deg()
+	for b := 0; b < 256; b++ {
+		t.mod[b] = polynomial(b).mulmod(1<<uint(k), p) ^ (polynomial(b) << uint(k))
+	}
+	return t
+}
+
+func appendByte(hash polynomial, b byte, pol polynomial) polynomial {
+	hash <<= 8
+	hash |= polynomial(b)
+	return hash.mod(pol)
+}
+
+// rabinHasher computes a rolling Rabin fingerprint over a byte stream using
+// a rabinWindowSize-byte sliding window. list_dedup.go only ever reads
+// Sum64 after feeding it a whole chunk's encoded bytes, using it as a plain
+// whole-block digest; nothing in this package inspects the digest mid-write
+// to find a content-defined boundary, despite the rolling window making
+// that possible in principle (e.g. via a mask on Sum64()).
+type rabinHasher struct {
+	tables *rabinTables
+	window [rabinWindowSize]byte
+	wpos   int
+	digest polynomial
+}
+
+func newRabinHasher() *rabinHasher {
+	return &rabinHasher{tables: getRabinTables()}
+}
+
+// Write feeds bytes through the rolling window, updating the digest.
+func (h *rabinHasher) Write(buf []byte) {
+	for _, b := range buf {
+		out := h.window[h.wpos]
+		h.window[h.wpos] = b
+		h.wpos = (h.wpos + 1) % rabinWindowSize
+
+		h.digest ^= h.tables.out[out]
+		index := byte(h.digest >> modTableShift)
+		h.digest <<= 8
+		h.digest |= polynomial(b)
+		h.digest ^= h.tables.mod[index]
+	}
+}
+
+// Sum64 returns the current fingerprint. It does not reset the hasher.
+func (h *rabinHasher) Sum64() uint64 {
+	return uint64(h.digest)
+}
+
+// Reset clears the hasher so it can be reused for the next block.
+func (h *rabinHasher) Reset() {
+	h.window = [rabinWindowSize]byte{}
+	h.wpos = 0
+	h.digest = 0
+}
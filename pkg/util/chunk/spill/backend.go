@@ -0,0 +1,45 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spill provides pluggable on-disk key-value backends used by
+// chunk.List to evict consumed chunks from memory once a soft limit is
+// crossed. The backend is intentionally a narrow interface so callers can
+// swap the bundled embedded store for leveldb, pebble, badger or anything
+// else without touching List's spill logic.
+package spill
+
+import "github.com/pingcap/errors"
+
+// ErrNotFound is returned by Backend.Get when the requested key is absent.
+var ErrNotFound = errors.New("spill: key not found")
+
+// Backend is an on-disk key-value store keyed by a chunk index. chunk.List
+// uses it to serialize and evict fully-consumed chunks, and to page them
+// back in on demand.
+type Backend interface {
+	// Open prepares the backend to serve Get/Put/Delete under dir, creating
+	// dir if necessary. It must be called exactly once before any other
+	// method and must fail if dir is already owned by another backend
+	// instance, so that concurrent executors never collide.
+	Open(dir string) error
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(key uint64) ([]byte, error)
+	// Put stores value under key, overwriting any previous value.
+	Put(key uint64, value []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key uint64) error
+	// Close releases all resources held by the backend. After Close, dir may
+	// be safely removed by the caller.
+	Close() error
+}
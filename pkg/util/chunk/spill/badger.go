@@ -0,0 +1,160 @@
+// Copyright 2024 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spill
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+const (
+	manifestFileName = "MANIFEST"
+	dataFileName     = "data.db"
+)
+
+// entryHeaderSize is the size, in bytes, of the fixed-width header written
+// before every value in the data file: the key, the value length and a
+// tombstone flag.
+const entryHeaderSize = 8 + 4 + 1
+
+// BadgerEmbeddedBackend is a small embedded key-value Backend, shaped after
+// BadgerDB's on-disk contract (an append-only value log guarded by a
+// directory-level MANIFEST) without pulling in the real dependency. It is
+// the default Backend used by chunk.List when no other implementation is
+// supplied; leveldb/pebble/badger-backed implementations of Backend can
+// replace it transparently.
+type BadgerEmbeddedBackend struct {
+	mu       sync.RWMutex
+	dir      string
+	manifest *os.File
+	data     *os.File
+	index    map[uint64]valueLocation
+}
+
+type valueLocation struct {
+	offset int64
+	length int64
+}
+
+// NewBadgerEmbeddedBackend creates an unopened BadgerEmbeddedBackend.
+func NewBadgerEmbeddedBackend() *BadgerEmbeddedBackend {
+	return &BadgerEmbeddedBackend{}
+}
+
+// Open implements Backend. dir is created if it does not exist; the presence
+// of a pre-existing MANIFEST causes Open to fail, since it would mean the
+// directory is already owned by another List.
+func (b *BadgerEmbeddedBackend) Open(dir string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Trace(err)
+	}
+	manifest, err := os.OpenFile(filepath.Join(dir, manifestFileName), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return errors.Errorf("spill: directory %q is already owned by another backend", dir)
+		}
+		return errors.Trace(err)
+	}
+	data, err := os.OpenFile(filepath.Join(dir, dataFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		manifest.Close()
+		return errors.Trace(err)
+	}
+
+	b.dir = dir
+	b.manifest = manifest
+	b.data = data
+	b.index = make(map[uint64]valueLocation)
+	return nil
+}
+
+// Get implements Backend.
+func (b *BadgerEmbeddedBackend) Get(key uint64) ([]byte, error) {
+	b.mu.RLock()
+	loc, ok := b.index[key]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	value := make([]byte, loc.length)
+	if _, err := b.data.ReadAt(value, loc.offset+entryHeaderSize); err != nil && err != io.EOF {
+		return nil, errors.Trace(err)
+	}
+	return value, nil
+}
+
+// Put implements Backend. Values are appended to the data file; Put never
+// rewrites an existing entry in place, it simply re-points the index at the
+// new tail entry, so old bytes become reclaimable garbage that a future
+// compaction pass could collect.
+func (b *BadgerEmbeddedBackend) Put(key uint64, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	offset, err := b.data.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	header := make([]byte, entryHeaderSize)
+	binary.LittleEndian.PutUint64(header[0:8], key)
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(value)))
+	header[12] = 0 // live entry
+
+	if _, err := b.data.Write(header); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := b.data.Write(value); err != nil {
+		return errors.Trace(err)
+	}
+
+	b.index[key] = valueLocation{offset: offset, length: int64(len(value))}
+	return nil
+}
+
+// Delete implements Backend.
+func (b *BadgerEmbeddedBackend) Delete(key uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.index, key)
+	return nil
+}
+
+// Close implements Backend.
+func (b *BadgerEmbeddedBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	if err := b.data.Close(); err != nil {
+		firstErr = err
+	}
+	if err := b.manifest.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr != nil {
+		return errors.Trace(firstErr)
+	}
+	return os.Remove(filepath.Join(b.dir, manifestFileName))
+}